@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DataDog/datadog-go/statsd"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/coinbase/mongobetween/proxy"
+)
+
+const shutdownTimeout = 30 * time.Second
+
+var (
+	network     = flag.String("network", "tcp", "network to listen on (tcp, tcp4, tcp6, unix)")
+	address     = flag.String("address", ":27016", "address to listen on")
+	unlink      = flag.Bool("unlink", false, "unlink existing unix socket at address before listening")
+	ping        = flag.Bool("ping", true, "ping the upstream mongo on connect")
+	label       = flag.String("label", "", "cluster label, added as a statsd tag and a logger field")
+	adminSocket = flag.String("admin-socket", "", "unix socket address for the admin inspection/control surface")
+	configPath  = flag.String("config", "", "path to a JSON file of the form {\"url\":..., \"failoverUrl\":...}; reread on SIGHUP")
+)
+
+// upstreamConfig is the shape of -config; it's reread by reloadUpstream on
+// every SIGHUP so operators can rotate credentials, change the upstream
+// URI, or set a failover cluster without restarting the process.
+type upstreamConfig struct {
+	URL         string `json:"url"`
+	FailoverURL string `json:"failoverUrl,omitempty"`
+}
+
+func reloadUpstream() (opts, failover *options.ClientOptions, err error) {
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var c upstreamConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, nil, err
+	}
+
+	opts = options.Client().ApplyURI(c.URL)
+	if c.FailoverURL != "" {
+		failover = options.Client().ApplyURI(c.FailoverURL)
+	}
+	return opts, failover, nil
+}
+
+func main() {
+	flag.Parse()
+
+	log, err := zap.NewProduction()
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = log.Sync()
+	}()
+
+	sd, err := statsd.New("127.0.0.1:8125")
+	if err != nil {
+		log.Fatal("Failed to create statsd client", zap.Error(err))
+	}
+
+	if *configPath == "" {
+		log.Fatal("-config is required")
+	}
+	opts, failover, err := reloadUpstream()
+	if err != nil {
+		log.Fatal("Failed to load -config", zap.Error(err))
+	}
+
+	p, err := proxy.NewProxy(log, sd, proxy.Config{AdminSocket: *adminSocket}, *label, *network, *address, *unlink, *ping, opts, failover)
+	if err != nil {
+		log.Fatal("Failed to create proxy", zap.Error(err))
+	}
+
+	go func() {
+		if err := p.RunAdmin(); err != nil {
+			log.Error("Admin surface exited", zap.Error(err))
+		}
+	}()
+
+	watchReload(log, p, reloadUpstream)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		log.Info("Shutting down", zap.Duration("timeout", shutdownTimeout))
+		p.ShutdownWithTimeout(shutdownTimeout)
+		p.StopAdmin()
+	}()
+
+	if err := p.Run(); err != nil {
+		log.Fatal("Proxy exited", zap.Error(err))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/coinbase/mongobetween/proxy"
+)
+
+// watchReload installs a SIGHUP handler that reloads p's upstream options
+// each time the signal is received, so operators can rotate credentials,
+// change the upstream URI, or promote a failover cluster without dropping
+// client connections or restarting the process.
+func watchReload(log *zap.Logger, p *proxy.Proxy, reload func() (opts, failover *options.ClientOptions, err error)) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		for range ch {
+			opts, failover, err := reload()
+			if err != nil {
+				log.Error("Failed to build reload options", zap.Error(err))
+				continue
+			}
+			if err := p.Reload(opts, failover); err != nil {
+				log.Error("Reload failed", zap.Error(err))
+			}
+		}
+	}()
+}
@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	slowQueryThreshold = 100 * time.Millisecond
+	slowQueryHistory   = 100
+)
+
+// slowQuery is one entry in the admin surface's rolling slow-query log.
+type slowQuery struct {
+	at         time.Time
+	collection string
+	op         string
+	duration   time.Duration
+}
+
+// opCounters is a per-collection count of operations handled, dumped by the
+// admin "opcounts" command.
+type opCounters struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func newOpCounters() *opCounters {
+	return &opCounters{counts: make(map[string]int64)}
+}
+
+func (oc *opCounters) record(collection string) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	oc.counts[collection]++
+}
+
+func (oc *opCounters) snapshot() map[string]int64 {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	out := make(map[string]int64, len(oc.counts))
+	for k, v := range oc.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// RecordOp feeds the admin op counters and the per-connection last-seen-op
+// field, and files the operation in the slow-query log if it ran past
+// slowQueryThreshold. It's called once per operation completed on id's
+// connection - currently from countingConn's wire protocol framer, which
+// only has an opcode to report (collection is empty), so opCounters falls
+// back to counting by op in that case. Once request parsing resolves a
+// real collection name, it'll take over as the counter key.
+func (p *Proxy) RecordOp(id uint64, collection, op string, cursorID int64, duration time.Duration) {
+	key := collection
+	if key == "" {
+		key = op
+	}
+	p.opCounters.record(key)
+
+	if ci, ok := p.conns.get(id); ok {
+		ci.setLastOp(op, cursorID)
+	}
+
+	if duration >= slowQueryThreshold {
+		p.recordSlowQuery(slowQuery{at: time.Now(), collection: collection, op: op, duration: duration})
+	}
+}
+
+func (p *Proxy) recordSlowQuery(q slowQuery) {
+	p.slowQueriesMu.Lock()
+	p.slowQueries = append(p.slowQueries, q)
+	if len(p.slowQueries) > slowQueryHistory {
+		p.slowQueries = p.slowQueries[len(p.slowQueries)-slowQueryHistory:]
+	}
+	subs := make([]chan slowQuery, len(p.slowQuerySubs))
+	copy(subs, p.slowQuerySubs)
+	p.slowQueriesMu.Unlock()
+
+	for _, ch := range subs {
+		select {
+		case ch <- q:
+		default:
+			// slow subscriber, drop rather than block RecordOp
+		}
+	}
+}
+
+func (p *Proxy) subscribeSlowQueries() (<-chan slowQuery, func()) {
+	ch := make(chan slowQuery, 16)
+
+	p.slowQueriesMu.Lock()
+	p.slowQuerySubs = append(p.slowQuerySubs, ch)
+	p.slowQueriesMu.Unlock()
+
+	cancel := func() {
+		p.slowQueriesMu.Lock()
+		defer p.slowQueriesMu.Unlock()
+		for i, c := range p.slowQuerySubs {
+			if c == ch {
+				p.slowQuerySubs = append(p.slowQuerySubs[:i], p.slowQuerySubs[i+1:]...)
+				break
+			}
+		}
+	}
+	return ch, cancel
+}
+
+// Admin serves a line-oriented inspection and control protocol over a
+// dedicated Unix socket, kept separate from the wire-protocol listener so
+// admin traffic never contends with client connections.
+type Admin struct {
+	log     *zap.Logger
+	proxy   *Proxy
+	address string
+
+	quit chan interface{}
+}
+
+// NewAdmin builds an Admin surface for p, listening on the given Unix
+// socket address.
+func NewAdmin(log *zap.Logger, p *Proxy, address string) *Admin {
+	return &Admin{
+		log:     log,
+		proxy:   p,
+		address: address,
+		quit:    make(chan interface{}),
+	}
+}
+
+// ListenAndServe opens the admin Unix socket and serves connections until
+// Shutdown is called.
+func (a *Admin) ListenAndServe() error {
+	_ = syscall.Unlink(a.address)
+
+	l, err := net.Listen("unix", a.address)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+
+	go func() {
+		<-a.quit
+		_ = l.Close()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			select {
+			case <-a.quit:
+				return nil
+			default:
+				a.log.Error("Admin accept failed", zap.Error(err))
+				continue
+			}
+		}
+		go a.handle(c)
+	}
+}
+
+func (a *Admin) Shutdown() {
+	defer func() {
+		_ = recover() // "close of closed channel" panic if Shutdown() was already called
+	}()
+	close(a.quit)
+}
+
+func (a *Admin) handle(c net.Conn) {
+	defer func() {
+		_ = c.Close()
+	}()
+
+	scanner := bufio.NewScanner(c)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if strings.EqualFold(fields[0], "SLOWLOG") && len(fields) > 1 && strings.EqualFold(fields[1], "TAIL") {
+			// Takes over the connection until the client disconnects.
+			a.streamSlowLog(c)
+			return
+		}
+
+		reply := a.dispatch(line)
+		if _, err := fmt.Fprintln(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+// streamSlowLog pushes every slow query recorded from now on to c, one per
+// line, until the client disconnects. This is the "live tail" the SLOWLOG
+// TAIL command offers, as opposed to plain SLOWLOG's point-in-time
+// snapshot.
+//
+// A client's disconnect only surfaces here as a write error, and writes only
+// happen when a slow query comes in - if none ever does, a dropped client
+// would otherwise go unnoticed forever, leaking its subscriber channel and
+// the connection itself. So a second goroutine reads c in parallel, purely
+// to detect that disconnect independently of write traffic. TAIL clients
+// aren't expected to send anything once they've issued the command, but a
+// stray byte shouldn't be mistaken for one leaving, so it keeps reading and
+// discarding until a real read error (EOF or otherwise) proves the peer is
+// actually gone.
+func (a *Admin) streamSlowLog(c net.Conn) {
+	ch, cancel := a.proxy.subscribeSlowQueries()
+	defer cancel()
+
+	if _, err := fmt.Fprintln(c, "OK streaming slow queries"); err != nil {
+		return
+	}
+
+	disconnected := make(chan struct{})
+	go func() {
+		defer close(disconnected)
+		b := make([]byte, 256)
+		for {
+			if _, err := c.Read(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case q := <-ch:
+			if _, err := fmt.Fprintln(c, formatSlowQuery(q)); err != nil {
+				return
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+func formatSlowQuery(q slowQuery) string {
+	return fmt.Sprintf("at=%s collection=%s op=%s duration=%s",
+		q.at.Format(time.RFC3339), q.collection, q.op, q.duration)
+}
+
+func (a *Admin) dispatch(line string) string {
+	fields := strings.Fields(line)
+	cmd := strings.ToUpper(fields[0])
+	args := fields[1:]
+
+	switch cmd {
+	case "LIST":
+		return a.cmdList()
+	case "CLOSE":
+		return a.cmdClose(args)
+	case "RELOAD":
+		return "ERR reload requires client options, use the operator SIGHUP flow instead"
+	case "FAILOVER":
+		return a.cmdFailover()
+	case "SHUTDOWN":
+		return a.cmdShutdown(args)
+	case "OPCOUNTS":
+		return a.cmdOpCounts()
+	case "SLOWLOG":
+		return a.cmdSlowLog()
+	default:
+		return fmt.Sprintf("ERR unknown command %q", fields[0])
+	}
+}
+
+func (a *Admin) cmdList() string {
+	stats := a.proxy.conns.list()
+	if len(stats) == 0 {
+		return "OK 0 connections"
+	}
+	lines := make([]string, 0, len(stats)+1)
+	lines = append(lines, fmt.Sprintf("OK %d connections", len(stats)))
+	for _, s := range stats {
+		lines = append(lines, fmt.Sprintf("id=%d remote=%s age=%s bytes_in=%d bytes_out=%d last_op=%s cursor_id=%d",
+			s.ID, s.RemoteAddr, s.Age.Round(time.Second), s.BytesIn, s.BytesOut, s.LastOp, s.CursorID))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (a *Admin) cmdClose(args []string) string {
+	if len(args) != 1 {
+		return "ERR usage: CLOSE <id>"
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return "ERR invalid connection id"
+	}
+	ci, ok := a.proxy.conns.get(id)
+	if !ok {
+		return fmt.Sprintf("ERR no connection with id %d", id)
+	}
+	_ = ci.conn.Close()
+	return "OK closed"
+}
+
+func (a *Admin) cmdFailover() string {
+	if err := a.proxy.Failover(); err != nil {
+		return fmt.Sprintf("ERR %s", err)
+	}
+	return "OK failed over"
+}
+
+func (a *Admin) cmdShutdown(args []string) string {
+	d := 30 * time.Second
+	if len(args) == 1 {
+		secs, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "ERR invalid timeout seconds"
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	go a.proxy.ShutdownWithTimeout(d)
+	return fmt.Sprintf("OK draining for up to %s", d)
+}
+
+func (a *Admin) cmdOpCounts() string {
+	counts := a.proxy.opCounters.snapshot()
+	if len(counts) == 0 {
+		return "OK 0 collections"
+	}
+	collections := make([]string, 0, len(counts))
+	for c := range counts {
+		collections = append(collections, c)
+	}
+	sort.Strings(collections)
+
+	lines := make([]string, 0, len(collections)+1)
+	lines = append(lines, fmt.Sprintf("OK %d collections", len(collections)))
+	for _, c := range collections {
+		lines = append(lines, fmt.Sprintf("%s=%d", c, counts[c]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cmdSlowLog returns the current slow-query history as a point-in-time
+// snapshot. For a live tail, issue "SLOWLOG TAIL" instead (handled directly
+// in handle, since it takes over the connection rather than replying once).
+func (a *Admin) cmdSlowLog() string {
+	a.proxy.slowQueriesMu.Lock()
+	qs := make([]slowQuery, len(a.proxy.slowQueries))
+	copy(qs, a.proxy.slowQueries)
+	a.proxy.slowQueriesMu.Unlock()
+
+	if len(qs) == 0 {
+		return "OK 0 slow queries"
+	}
+	lines := make([]string, 0, len(qs)+1)
+	lines = append(lines, fmt.Sprintf("OK %d slow queries", len(qs)))
+	for _, q := range qs {
+		lines = append(lines, formatSlowQuery(q))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminDispatchList(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	if got := a.dispatch("LIST"); got != "OK 0 connections" {
+		t.Fatalf("dispatch(LIST) = %q, want %q", got, "OK 0 connections")
+	}
+}
+
+func TestAdminDispatchCloseValidatesArgs(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	if got := a.dispatch("CLOSE"); got != "ERR usage: CLOSE <id>" {
+		t.Fatalf("dispatch(CLOSE) = %q", got)
+	}
+	if got := a.dispatch("CLOSE notanumber"); got != "ERR invalid connection id" {
+		t.Fatalf("dispatch(CLOSE notanumber) = %q", got)
+	}
+	if got := a.dispatch("CLOSE 42"); got != "ERR no connection with id 42" {
+		t.Fatalf("dispatch(CLOSE 42) = %q", got)
+	}
+}
+
+func TestAdminDispatchFailoverWithoutFailoverConfigured(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	got := a.dispatch("FAILOVER")
+	if !strings.HasPrefix(got, "ERR") {
+		t.Fatalf("dispatch(FAILOVER) = %q, want an ERR reply with no failover configured", got)
+	}
+}
+
+func TestAdminDispatchOpCounts(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	if got := a.dispatch("OPCOUNTS"); got != "OK 0 collections" {
+		t.Fatalf("dispatch(OPCOUNTS) = %q, want %q", got, "OK 0 collections")
+	}
+
+	p.opCounters.record("widgets")
+	p.opCounters.record("widgets")
+	if got := a.dispatch("OPCOUNTS"); got != "OK 1 collections\nwidgets=2" {
+		t.Fatalf("dispatch(OPCOUNTS) = %q", got)
+	}
+}
+
+func TestAdminDispatchUnknownCommand(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	if got := a.dispatch("BOGUS"); got != `ERR unknown command "BOGUS"` {
+		t.Fatalf("dispatch(BOGUS) = %q", got)
+	}
+}
+
+func TestStreamSlowLogReturnsWhenClientDisconnectsWithNoSlowQuery(t *testing.T) {
+	p := newTestProxy()
+	a := NewAdmin(p.log, p, "")
+
+	client, server := net.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.handle(server)
+	}()
+
+	if _, err := client.Write([]byte("SLOWLOG TAIL\n")); err != nil {
+		t.Fatalf("writing SLOWLOG TAIL: %v", err)
+	}
+
+	reader := bufio.NewReader(client)
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatalf("reading the streaming ack: %v", err)
+	}
+
+	// No slow query is ever recorded, so the only way handle can notice the
+	// client going away is the disconnect-detecting goroutine inside
+	// streamSlowLog - without it, this would hang until the test times out.
+	_ = client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("a.handle did not return after the SLOWLOG TAIL client disconnected")
+	}
+}
@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// connInfo holds the per-connection state the admin surface reports:
+// identity, how long it's been open, byte counters, and the last operation
+// it was seen handling. bytesIn/bytesOut/lastOp/cursorID are updated from
+// the connection's own handling goroutine, so they're kept outside the
+// connMap's own lock.
+type connInfo struct {
+	id         uint64
+	conn       net.Conn
+	remoteAddr string
+	openedAt   time.Time
+
+	bytesIn  int64
+	bytesOut int64
+
+	mu       sync.Mutex
+	lastOp   string
+	cursorID int64
+}
+
+func (ci *connInfo) addBytesIn(n int64)  { atomic.AddInt64(&ci.bytesIn, n) }
+func (ci *connInfo) addBytesOut(n int64) { atomic.AddInt64(&ci.bytesOut, n) }
+
+func (ci *connInfo) setLastOp(op string, cursorID int64) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	ci.lastOp = op
+	ci.cursorID = cursorID
+}
+
+// ConnStats is a point-in-time snapshot of a tracked connection, suitable
+// for the admin listing endpoint.
+type ConnStats struct {
+	ID         uint64
+	RemoteAddr string
+	Age        time.Duration
+	BytesIn    int64
+	BytesOut   int64
+	LastOp     string
+	CursorID   int64
+}
+
+func (ci *connInfo) snapshot() ConnStats {
+	ci.mu.Lock()
+	lastOp, cursorID := ci.lastOp, ci.cursorID
+	ci.mu.Unlock()
+
+	return ConnStats{
+		ID:         ci.id,
+		RemoteAddr: ci.remoteAddr,
+		Age:        time.Since(ci.openedAt),
+		BytesIn:    atomic.LoadInt64(&ci.bytesIn),
+		BytesOut:   atomic.LoadInt64(&ci.bytesOut),
+		LastOp:     lastOp,
+		CursorID:   cursorID,
+	}
+}
+
+// connMap tracks live client connections by a monotonically incremented id,
+// so the proxy can enumerate, count, and forcibly close them independently
+// of the accept loop's own goroutine lifecycle.
+type connMap struct {
+	mu     sync.RWMutex
+	conns  map[uint64]*connInfo
+	nextID uint64
+}
+
+func newConnMap() *connMap {
+	return &connMap{
+		conns: make(map[uint64]*connInfo),
+	}
+}
+
+// add registers c and returns the id it was assigned along with the
+// connInfo the accept loop's handler can update as it processes requests.
+func (cm *connMap) add(c net.Conn) (uint64, *connInfo) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.nextID++
+	id := cm.nextID
+	ci := &connInfo{
+		id:         id,
+		conn:       c,
+		remoteAddr: c.RemoteAddr().String(),
+		openedAt:   time.Now(),
+	}
+	cm.conns[id] = ci
+	return id, ci
+}
+
+func (cm *connMap) remove(id uint64) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	delete(cm.conns, id)
+}
+
+func (cm *connMap) length() int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return len(cm.conns)
+}
+
+func (cm *connMap) get(id uint64) (*connInfo, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	ci, ok := cm.conns[id]
+	return ci, ok
+}
+
+// list returns a snapshot of every tracked connection, ordered by id.
+func (cm *connMap) list() []ConnStats {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	stats := make([]ConnStats, 0, len(cm.conns))
+	for _, ci := range cm.conns {
+		stats = append(stats, ci.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
+// closeAll forcibly closes every tracked connection. It does not remove
+// them from the map; callers that accept the close as final should let the
+// accept loop's own bookkeeping clean up the entries.
+func (cm *connMap) closeAll() {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	for _, ci := range cm.conns {
+		_ = ci.conn.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnMapAddRemoveLength(t *testing.T) {
+	cm := newConnMap()
+	c1, _ := net.Pipe()
+	c2, _ := net.Pipe()
+
+	id1, ci1 := cm.add(c1)
+	id2, _ := cm.add(c2)
+	if id1 == id2 {
+		t.Fatalf("expected distinct ids, got %d and %d", id1, id2)
+	}
+	if got := cm.length(); got != 2 {
+		t.Fatalf("length() = %d, want 2", got)
+	}
+
+	got, ok := cm.get(id1)
+	if !ok || got != ci1 {
+		t.Fatalf("get(%d) = %v, %v; want %v, true", id1, got, ok, ci1)
+	}
+
+	cm.remove(id1)
+	if got := cm.length(); got != 1 {
+		t.Fatalf("length() = %d after remove, want 1", got)
+	}
+	if _, ok := cm.get(id1); ok {
+		t.Fatalf("get(%d) still found after remove", id1)
+	}
+}
+
+func TestConnMapCloseAll(t *testing.T) {
+	cm := newConnMap()
+	client, server := net.Pipe()
+	defer func() {
+		_ = client.Close()
+	}()
+	cm.add(server)
+
+	cm.closeAll()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Write([]byte("x"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected write to a peer closed by closeAll to error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for closeAll to take effect")
+	}
+}
+
+func TestConnMapList(t *testing.T) {
+	cm := newConnMap()
+	c1, _ := net.Pipe()
+	c2, _ := net.Pipe()
+
+	id1, _ := cm.add(c1)
+	id2, _ := cm.add(c2)
+
+	stats := cm.list()
+	if len(stats) != 2 {
+		t.Fatalf("list() returned %d stats, want 2", len(stats))
+	}
+	if stats[0].ID != id1 || stats[1].ID != id2 {
+		t.Fatalf("list() = %v, want ordered by id [%d %d]", stats, id1, id2)
+	}
+}
@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// MongoDB wire protocol opcodes (see the "Old Wire Protocol" and
+// OP_MSG/OP_COMPRESSED sections of the MongoDB wire protocol spec).
+const (
+	opReply       = 1
+	opUpdate      = 2001
+	opInsert      = 2002
+	opQuery       = 2004
+	opGetMore     = 2005
+	opDelete      = 2006
+	opKillCursors = 2007
+	opCompressed  = 2012
+	opMsg         = 2013
+)
+
+func opCodeName(code int32) string {
+	switch code {
+	case opReply:
+		return "OP_REPLY"
+	case opUpdate:
+		return "OP_UPDATE"
+	case opInsert:
+		return "OP_INSERT"
+	case opQuery:
+		return "OP_QUERY"
+	case opGetMore:
+		return "OP_GET_MORE"
+	case opDelete:
+		return "OP_DELETE"
+	case opKillCursors:
+		return "OP_KILL_CURSORS"
+	case opCompressed:
+		return "OP_COMPRESSED"
+	case opMsg:
+		return "OP_MSG"
+	default:
+		return "OP_UNKNOWN"
+	}
+}
+
+const wireHeaderLen = 16
+
+// bodyPeekLen is how many leading body bytes wireFramer buffers alongside
+// the header: OP_MSG's flagBits and OP_COMPRESSED's originalOpCode are both
+// a 4-byte field at body offset 0.
+const bodyPeekLen = 4
+
+// opMsgMoreToCome marks an OP_MSG as not expecting a reply (see the OP_MSG
+// section of the MongoDB wire protocol spec).
+const opMsgMoreToCome = 0x2
+
+// wireFrame is one fully-parsed MongoDB wire protocol message header:
+// requestID, responseTo and opCode, all little-endian int32s (the header's
+// messageLength is only used by wireFramer to size the body and isn't kept),
+// plus flagBits for OP_MSG and wrappedOpCode for OP_COMPRESSED (zero and
+// meaningless for every other opcode).
+type wireFrame struct {
+	requestID     int32
+	responseTo    int32
+	opCode        int32
+	flagBits      uint32
+	wrappedOpCode int32
+}
+
+// wireFramer is a stateful header parser for one direction of a wire
+// protocol stream. Reads/writes don't line up with message boundaries, so
+// it buffers a partial header and counts down the remaining body across
+// calls to feed. It also peeks at the first few body bytes so it can report
+// OP_MSG's flagBits, or OP_COMPRESSED's wrapped opcode, alongside the header
+// - both sit at the same offset, so one peek buffer covers either.
+type wireFramer struct {
+	needHeader  bool
+	header      []byte
+	pendingBody int64
+	bodyPeek    []byte
+	frame       wireFrame
+}
+
+func newWireFramer() *wireFramer {
+	return &wireFramer{
+		needHeader: true,
+		header:     make([]byte, 0, wireHeaderLen),
+		bodyPeek:   make([]byte, 0, bodyPeekLen),
+	}
+}
+
+// feed advances the state machine with newly seen bytes, calling onComplete
+// once per full message.
+func (f *wireFramer) feed(data []byte, onComplete func(wireFrame)) {
+	for len(data) > 0 {
+		if f.needHeader {
+			need := wireHeaderLen - len(f.header)
+			take := need
+			if take > len(data) {
+				take = len(data)
+			}
+			f.header = append(f.header, data[:take]...)
+			data = data[take:]
+
+			if len(f.header) < wireHeaderLen {
+				return
+			}
+
+			messageLength := int32(binary.LittleEndian.Uint32(f.header[0:4]))
+			f.frame = wireFrame{
+				requestID:  int32(binary.LittleEndian.Uint32(f.header[4:8])),
+				responseTo: int32(binary.LittleEndian.Uint32(f.header[8:12])),
+				opCode:     int32(binary.LittleEndian.Uint32(f.header[12:16])),
+			}
+			f.pendingBody = int64(messageLength) - wireHeaderLen
+			if f.pendingBody < 0 {
+				f.pendingBody = 0
+			}
+			f.needHeader = false
+			f.header = f.header[:0]
+			f.bodyPeek = f.bodyPeek[:0]
+			continue
+		}
+
+		take := f.pendingBody
+		if take > int64(len(data)) {
+			take = int64(len(data))
+		}
+		chunk := data[:take]
+		if len(f.bodyPeek) < bodyPeekLen {
+			need := bodyPeekLen - len(f.bodyPeek)
+			if need > len(chunk) {
+				need = len(chunk)
+			}
+			f.bodyPeek = append(f.bodyPeek, chunk[:need]...)
+		}
+		data = data[take:]
+		f.pendingBody -= take
+
+		if f.pendingBody <= 0 {
+			if len(f.bodyPeek) == bodyPeekLen {
+				switch f.frame.opCode {
+				case opMsg:
+					f.frame.flagBits = binary.LittleEndian.Uint32(f.bodyPeek)
+				case opCompressed:
+					f.frame.wrappedOpCode = int32(binary.LittleEndian.Uint32(f.bodyPeek))
+				}
+			}
+			onComplete(f.frame)
+			f.needHeader = true
+		}
+	}
+}
+
+type pendingOp struct {
+	op    string
+	start time.Time
+}
+
+// countingConn wraps a client net.Conn to track bytes read/written for the
+// admin LIST command, and to frame the MongoDB wire protocol messages
+// flowing in both directions just enough to report an opcode-level
+// "last_op"/slow-query duration and feed the admin op counters. It
+// correlates each response back to its request via the wire protocol's
+// requestID/responseTo fields, so the duration RecordOp sees is the real
+// end-to-end time the proxy took to get a reply back to the client, not
+// just how long the request took to arrive. It does not parse BSON, so it
+// can't report the collection name or cursor id a full command parser
+// would have access to (that lives in the mongo package's request
+// handling, not here) - those fields stay empty/zero until that
+// integration exists.
+type countingConn struct {
+	net.Conn
+	proxy *Proxy
+	id    uint64
+	ci    *connInfo
+
+	in  *wireFramer
+	out *wireFramer
+
+	pendingMu sync.Mutex
+	pending   map[int32]pendingOp
+	lastSweep time.Time
+}
+
+func newCountingConn(c net.Conn, p *Proxy, id uint64, ci *connInfo) *countingConn {
+	return &countingConn{
+		Conn:    c,
+		proxy:   p,
+		id:      id,
+		ci:      ci,
+		in:      newWireFramer(),
+		out:     newWireFramer(),
+		pending: make(map[int32]pendingOp),
+	}
+}
+
+func (cc *countingConn) Read(b []byte) (int, error) {
+	n, err := cc.Conn.Read(b)
+	if n > 0 {
+		cc.ci.addBytesIn(int64(n))
+		cc.in.feed(b[:n], cc.onRequest)
+	}
+	return n, err
+}
+
+func (cc *countingConn) Write(b []byte) (int, error) {
+	n, err := cc.Conn.Write(b)
+	if n > 0 {
+		cc.ci.addBytesOut(int64(n))
+		cc.out.feed(b[:n], cc.onResponse)
+	}
+	return n, err
+}
+
+// expectsReply reports whether f's opcode will provoke a response from the
+// server - if it won't, tracking it in pending would never get cleaned up by
+// onResponse and would leak for the life of the connection. The legacy
+// write opcodes (OP_INSERT/OP_UPDATE/OP_DELETE/OP_KILL_CURSORS) are
+// fire-and-forget at the wire level by design - a client wanting an
+// acknowledgement issues a separate getLastError query for it, which is a
+// distinct message this framer sees on its own. OP_MSG carries the same
+// information in its moreToCome flag. OP_COMPRESSED defers to the opcode it
+// wraps, which wireFramer reads straight off the uncompressed header prefix
+// without touching the compressed payload; if that prefix never arrived
+// (e.g. a message shorter than the peek window), assume a reply is coming
+// rather than risk silently dropping real round-trip tracking.
+func expectsReply(f wireFrame) bool {
+	opCode := f.opCode
+	if opCode == opCompressed {
+		opCode = f.wrappedOpCode
+	}
+	switch opCode {
+	case opQuery, opGetMore:
+		return true
+	case opMsg:
+		return f.flagBits&opMsgMoreToCome == 0
+	case opInsert, opUpdate, opDelete, opKillCursors:
+		return false
+	default:
+		return true
+	}
+}
+
+// maxPendingAge bounds how long an entry can sit in cc.pending. expectsReply
+// can't always tell a genuinely unacknowledged op apart from one that's just
+// slow - an OP_COMPRESSED frame hides its wrapped OP_MSG's moreToCome flag in
+// the still-compressed payload, so that case is deliberately optimistic and
+// tracks it anyway. The sweep in onRequest is the backstop: whatever slips
+// past expectsReply still can't accumulate forever.
+const maxPendingAge = 5 * time.Minute
+
+// pendingSweepInterval caps how often onRequest pays for a sweep of
+// cc.pending. A sweep is O(len(pending)), so running it on every request
+// would turn a connection pipelining many requests into O(n^2) work; gating
+// it on wall-clock time instead keeps the common case an O(1) map insert.
+const pendingSweepInterval = maxPendingAge / 10
+
+// onRequest fires once a full request has been read from the client. For
+// requests that will get a reply, it stamps the request's arrival as the
+// start of its round trip, keyed by requestID so the matching response can
+// look it up. Requests that never get a reply are recorded immediately
+// instead, with no duration to measure, rather than added to pending where
+// they'd sit forever.
+func (cc *countingConn) onRequest(f wireFrame) {
+	if !expectsReply(f) {
+		cc.proxy.RecordOp(cc.id, "", opCodeName(f.opCode), 0, 0)
+		return
+	}
+
+	now := time.Now()
+	cc.pendingMu.Lock()
+	if now.Sub(cc.lastSweep) > pendingSweepInterval {
+		for id, p := range cc.pending {
+			if now.Sub(p.start) > maxPendingAge {
+				delete(cc.pending, id)
+			}
+		}
+		cc.lastSweep = now
+	}
+	cc.pending[f.requestID] = pendingOp{op: opCodeName(f.opCode), start: now}
+	cc.pendingMu.Unlock()
+}
+
+// onResponse fires once a full response has been written back to the
+// client. It looks up the request it answers via responseTo and reports
+// the round trip to RecordOp; responses with no matching request (e.g. one
+// whose request was swept from pending after maxPendingAge) are ignored
+// rather than recorded with a bogus duration.
+func (cc *countingConn) onResponse(f wireFrame) {
+	cc.pendingMu.Lock()
+	p, ok := cc.pending[f.responseTo]
+	if ok {
+		delete(cc.pending, f.responseTo)
+	}
+	cc.pendingMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	cc.proxy.RecordOp(cc.id, "", p.op, 0, time.Since(p.start))
+}
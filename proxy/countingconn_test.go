@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestExpectsReplyLegacyWrites(t *testing.T) {
+	for _, op := range []int32{opInsert, opUpdate, opDelete, opKillCursors} {
+		if expectsReply(wireFrame{opCode: op}) {
+			t.Fatalf("expectsReply(opCode=%d) = true, want false for a fire-and-forget legacy write", op)
+		}
+	}
+	for _, op := range []int32{opQuery, opGetMore} {
+		if !expectsReply(wireFrame{opCode: op}) {
+			t.Fatalf("expectsReply(opCode=%d) = false, want true", op)
+		}
+	}
+}
+
+func TestExpectsReplyOpMsgMoreToCome(t *testing.T) {
+	if expectsReply(wireFrame{opCode: opMsg, flagBits: opMsgMoreToCome}) {
+		t.Fatalf("expectsReply(OP_MSG, moreToCome) = true, want false")
+	}
+	if !expectsReply(wireFrame{opCode: opMsg}) {
+		t.Fatalf("expectsReply(OP_MSG) = false, want true")
+	}
+}
+
+func TestExpectsReplyOpCompressedDefersToWrappedOpCode(t *testing.T) {
+	if expectsReply(wireFrame{opCode: opCompressed, wrappedOpCode: opInsert}) {
+		t.Fatalf("expectsReply(OP_COMPRESSED wrapping OP_INSERT) = true, want false")
+	}
+	if !expectsReply(wireFrame{opCode: opCompressed, wrappedOpCode: opQuery}) {
+		t.Fatalf("expectsReply(OP_COMPRESSED wrapping OP_QUERY) = false, want true")
+	}
+}
+
+func TestOnRequestSkipsPendingForFireAndForgetOps(t *testing.T) {
+	p := newTestProxy()
+	c1, c2 := net.Pipe()
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+
+	id, ci := p.conns.add(c1)
+	cc := newCountingConn(c1, p, id, ci)
+
+	cc.onRequest(wireFrame{requestID: 1, opCode: opInsert})
+	if len(cc.pending) != 0 {
+		t.Fatalf("pending = %v, want empty after a fire-and-forget op", cc.pending)
+	}
+	if got := p.opCounters.snapshot()["OP_INSERT"]; got != 1 {
+		t.Fatalf("OP_INSERT count = %d, want 1", got)
+	}
+}
+
+func TestOnRequestTracksAndClearsPendingForQuery(t *testing.T) {
+	p := newTestProxy()
+	c1, c2 := net.Pipe()
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+
+	id, ci := p.conns.add(c1)
+	cc := newCountingConn(c1, p, id, ci)
+
+	cc.onRequest(wireFrame{requestID: 2, opCode: opQuery})
+	if len(cc.pending) != 1 {
+		t.Fatalf("pending = %v, want one entry for a tracked op", cc.pending)
+	}
+
+	cc.onResponse(wireFrame{responseTo: 2})
+	if len(cc.pending) != 0 {
+		t.Fatalf("pending = %v, want empty once the matching response arrives", cc.pending)
+	}
+}
+
+func TestOnRequestSweepsStalePendingEntries(t *testing.T) {
+	p := newTestProxy()
+	c1, c2 := net.Pipe()
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+
+	id, ci := p.conns.add(c1)
+	cc := newCountingConn(c1, p, id, ci)
+
+	cc.pending[1] = pendingOp{op: "OP_MSG", start: time.Now().Add(-2 * maxPendingAge)}
+
+	cc.onRequest(wireFrame{requestID: 2, opCode: opQuery})
+
+	if _, ok := cc.pending[1]; ok {
+		t.Fatalf("pending still has a stale entry past maxPendingAge")
+	}
+	if _, ok := cc.pending[2]; !ok {
+		t.Fatalf("pending is missing the request that just came in")
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"sync/atomic"
+
+	"github.com/coinbase/mongobetween/mongo"
+)
+
+// mongoGeneration pairs the primary and failover *mongo.Mongo clients a
+// batch of connections was handed at accept time, and tracks how many of
+// those connections are still using it. Reload dials a new generation and
+// retires the old one; the old one's clients are only closed once every
+// connection still holding a reference has released it, so a reload never
+// pulls the rug out from under an in-flight connection the way closing
+// immediately would.
+type mongoGeneration struct {
+	m  *mongo.Mongo
+	mf *mongo.Mongo
+
+	refs    int32 // atomic
+	retired int32 // atomic bool
+}
+
+// acquire marks one more connection as using this generation. Callers must
+// release exactly once when they're done.
+func (g *mongoGeneration) acquire() {
+	atomic.AddInt32(&g.refs, 1)
+}
+
+// release marks a connection as done with this generation, closing the
+// underlying clients if the generation has been retired and this was the
+// last reference.
+func (g *mongoGeneration) release() {
+	if atomic.AddInt32(&g.refs, -1) == 0 && atomic.LoadInt32(&g.retired) == 1 {
+		g.closeNow()
+	}
+}
+
+// retire marks the generation as superseded, closing its clients right
+// away if nothing is using it, or as soon as the last holder releases it.
+func (g *mongoGeneration) retire() {
+	atomic.StoreInt32(&g.retired, 1)
+	if atomic.LoadInt32(&g.refs) == 0 {
+		g.closeNow()
+	}
+}
+
+func (g *mongoGeneration) closeNow() {
+	g.m.Close()
+	if g.mf != nil {
+		g.mf.Close()
+	}
+}
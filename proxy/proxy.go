@@ -33,6 +33,18 @@ type Proxy struct {
 
 	quit chan interface{}
 	kill chan interface{}
+
+	conns *connMap
+
+	mongoMu sync.RWMutex
+	gen     *mongoGeneration
+
+	opCounters    *opCounters
+	slowQueriesMu sync.Mutex
+	slowQueries   []slowQuery
+	slowQuerySubs []chan slowQuery
+
+	admin *Admin
 }
 
 func NewProxy(log *zap.Logger, sd *statsd.Client, config Config, label, network, address string, unlink, ping bool, opts, failover *options.ClientOptions) (*Proxy, error) {
@@ -45,7 +57,7 @@ func NewProxy(log *zap.Logger, sd *statsd.Client, config Config, label, network,
 			return nil, err
 		}
 	}
-	return &Proxy{
+	p := &Proxy{
 		log:    log,
 		statsd: sd,
 		config: config,
@@ -59,13 +71,37 @@ func NewProxy(log *zap.Logger, sd *statsd.Client, config Config, label, network,
 
 		quit: make(chan interface{}),
 		kill: make(chan interface{}),
-	}, nil
+
+		conns:      newConnMap(),
+		opCounters: newOpCounters(),
+	}
+
+	if config.AdminSocket != "" {
+		p.admin = NewAdmin(p.log, p, config.AdminSocket)
+	}
+
+	return p, nil
 }
 
 func (p *Proxy) Run() error {
 	return p.run()
 }
 
+// RunAdmin starts the admin inspection/control surface on config.AdminSocket
+// and blocks until it's shut down. It's a no-op if no socket is configured,
+// and is meant to be run in its own goroutine alongside Run so admin traffic
+// never shares a listener with client connections. p.admin is built once in
+// NewProxy, before any goroutines exist, so StopAdmin can safely read it
+// from a different goroutine (e.g. a signal handler) with no further
+// synchronization.
+func (p *Proxy) RunAdmin() error {
+	if p.admin == nil {
+		return nil
+	}
+
+	return p.admin.ListenAndServe()
+}
+
 func (p *Proxy) Shutdown() {
 	defer func() {
 		_ = recover() // "close of closed channel" panic if Shutdown() was already called
@@ -73,6 +109,44 @@ func (p *Proxy) Shutdown() {
 	close(p.quit)
 }
 
+// StopAdmin stops the admin inspection/control surface. It's deliberately
+// not called from Shutdown/Kill: operators driving a graceful drain via
+// ShutdownWithTimeout rely on LIST/OPCOUNTS over the admin socket to watch
+// that drain in progress, so admin's lifecycle is tied to the process, not
+// to the wire-protocol listener. Call this once the process is actually
+// exiting.
+func (p *Proxy) StopAdmin() {
+	if p.admin != nil {
+		p.admin.Shutdown()
+	}
+}
+
+// ShutdownWithTimeout closes the listener to stop new accepts, then waits up
+// to d for the connections already in flight to finish on their own. Any
+// still open when the deadline passes are force-closed via the existing
+// kill path, so this never blocks longer than d.
+func (p *Proxy) ShutdownWithTimeout(d time.Duration) {
+	p.Shutdown()
+
+	deadline := time.After(d)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if p.conns.length() == 0 {
+			return
+		}
+		select {
+		case <-deadline:
+			p.log.Warn("Drain deadline exceeded, force closing remaining connections", zap.Int("remaining", p.conns.length()))
+			p.conns.closeAll()
+			p.Kill()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func (p *Proxy) Kill() {
 	p.Shutdown()
 
@@ -103,21 +177,103 @@ func (p *Proxy) run() error {
 	if err != nil {
 		return err
 	}
-	defer m.Close()
 
 	var mf *mongo.Mongo
 	if p.failover != nil {
 		mf, err = mongo.Connect(p.log, p.statsd, p.failover, p.ping)
 		if err != nil {
+			m.Close()
+			return err
+		}
+	}
+
+	gen := &mongoGeneration{m: m, mf: mf}
+	p.mongoMu.Lock()
+	p.gen = gen
+	p.mongoMu.Unlock()
+
+	// retire closes the generation's clients once nothing still holds a
+	// reference to it - by the time run() returns, accept()'s wg.Wait() has
+	// already waited out every connection's release(), so this is safe to
+	// call unconditionally, whether or not Reload swapped p.gen in the
+	// meantime.
+	defer gen.retire()
+
+	return p.listen()
+}
+
+// acquireMongo returns the *mongoGeneration new connections should use,
+// with a reference already held on the caller's behalf; the caller must
+// call release() on it once the connection is done. Reload and Failover
+// swap p.gen under mongoMu, so callers always see a consistent pair
+// without blocking on in-flight operations, and a generation's clients are
+// never closed while a connection still holds a reference to it.
+func (p *Proxy) acquireMongo() *mongoGeneration {
+	p.mongoMu.RLock()
+	defer p.mongoMu.RUnlock()
+	g := p.gen
+	g.acquire()
+	return g
+}
+
+// Reload dials a new upstream (and optional failover) with opts, pings it if
+// configured to do so, then atomically swaps it in so every new connection
+// accepted afterwards uses it. Connections already in flight keep the
+// generation (and its *mongo.Mongo pointers) they were handed at accept
+// time, and the old generation's clients are closed only once every
+// connection still holding a reference to it has finished and released it.
+func (p *Proxy) Reload(opts, failover *options.ClientOptions) error {
+	m, err := mongo.Connect(p.log, p.statsd, opts, p.ping)
+	if err != nil {
+		return err
+	}
+
+	var mf *mongo.Mongo
+	if failover != nil {
+		mf, err = mongo.Connect(p.log, p.statsd, failover, p.ping)
+		if err != nil {
+			m.Close()
 			return err
 		}
-		defer mf.Close()
 	}
 
-	return p.listen(m, mf)
+	newGen := &mongoGeneration{m: m, mf: mf}
+
+	p.mongoMu.Lock()
+	oldGen := p.gen
+	p.gen = newGen
+	p.opts, p.failover = opts, failover
+	p.mongoMu.Unlock()
+
+	oldGen.retire()
+
+	p.log.Info("Reloaded upstream mongo clients")
+	return nil
+}
+
+// Failover promotes the failover cluster to primary at runtime by swapping
+// the active and failover roles in place; no new connections are dialed
+// and the current generation is not retired, since its clients stay live
+// under the new generation - only their roles change.
+func (p *Proxy) Failover() error {
+	p.mongoMu.Lock()
+	defer p.mongoMu.Unlock()
+
+	if p.failover == nil {
+		return fmt.Errorf("no failover cluster configured")
+	}
+	if p.gen == nil {
+		return fmt.Errorf("upstream not connected yet")
+	}
+
+	p.gen = &mongoGeneration{m: p.gen.mf, mf: p.gen.m}
+	p.opts, p.failover = p.failover, p.opts
+
+	p.log.Info("Promoted failover cluster to primary")
+	return nil
 }
 
-func (p *Proxy) listen(m, mf *mongo.Mongo) error {
+func (p *Proxy) listen() error {
 	if strings.Contains(p.network, "unix") {
 		oldUmask := syscall.Umask(0)
 		defer syscall.Umask(oldUmask)
@@ -141,11 +297,11 @@ func (p *Proxy) listen(m, mf *mongo.Mongo) error {
 		}
 	}()
 
-	p.accept(l, m, mf)
+	p.accept(l)
 	return nil
 }
 
-func (p *Proxy) accept(l net.Listener, m, mf *mongo.Mongo) {
+func (p *Proxy) accept(l net.Listener) {
 	var wg sync.WaitGroup
 	defer func() {
 		p.log.Info("Waiting for open connections")
@@ -174,11 +330,21 @@ func (p *Proxy) accept(l net.Listener, m, mf *mongo.Mongo) {
 
 		done := make(chan interface{})
 
+		id, ci := p.conns.add(c)
+		_ = p.statsd.Gauge("tracked_connections", float64(p.conns.length()), []string{}, 1)
+		cc := newCountingConn(c, p, id, ci)
+
+		gen := p.acquireMongo()
+
 		wg.Add(1)
 		opened("connection_opened", []string{})
 		go func() {
 			log.Info("Accept")
-			handleConnection(log, p.statsd, p.config, c, m, mf, p.kill)
+			handleConnection(log, p.statsd, p.config, cc, gen.m, gen.mf, p.kill)
+			gen.release()
+
+			p.conns.remove(id)
+			_ = p.statsd.Gauge("tracked_connections", float64(p.conns.length()), []string{}, 1)
 
 			_ = c.Close()
 			log.Info("Close")
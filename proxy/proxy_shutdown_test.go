@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestProxy() *Proxy {
+	return &Proxy{
+		log:        zap.NewNop(),
+		conns:      newConnMap(),
+		opCounters: newOpCounters(),
+		quit:       make(chan interface{}),
+		kill:       make(chan interface{}),
+	}
+}
+
+func TestShutdownWithTimeoutForceClosesAfterDeadline(t *testing.T) {
+	p := newTestProxy()
+
+	client, server := net.Pipe()
+	defer func() {
+		_ = client.Close()
+	}()
+	p.conns.add(server)
+
+	start := time.Now()
+	p.ShutdownWithTimeout(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("returned after %s, expected to wait at least the drain deadline", elapsed)
+	}
+
+	select {
+	case <-p.quit:
+	default:
+		t.Fatalf("expected quit channel to be closed")
+	}
+	select {
+	case <-p.kill:
+	default:
+		t.Fatalf("expected kill channel to be closed once the deadline passed with connections still open")
+	}
+}
+
+func TestShutdownWithTimeoutReturnsEarlyWhenDrained(t *testing.T) {
+	p := newTestProxy()
+
+	start := time.Now()
+	p.ShutdownWithTimeout(time.Second)
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("took %s to return with no tracked connections, expected an early return", elapsed)
+	}
+
+	select {
+	case <-p.kill:
+		t.Fatalf("kill channel closed even though the drain completed before the deadline")
+	default:
+	}
+}